@@ -57,6 +57,14 @@ var (
 		"Standard_NC8as_T4_v3":  true,
 		"Standard_NC16as_T4_v3": true,
 		"Standard_NC64as_T4_v3": true,
+		// A100
+		"Standard_NC24ads_A100_v4": true,
+		"Standard_NC48ads_A100_v4": true,
+		"Standard_NC96ads_A100_v4": true,
+		"Standard_ND96asr_v4":      true,
+		// H100
+		"Standard_NC40ads_H100_v5":  true,
+		"Standard_NC80adis_H100_v5": true,
 	}
 )
 
@@ -118,13 +126,107 @@ func ValidateDNSPrefix(dnsName string) error {
 	return nil
 }
 
-// IsSgxEnabledSKU determines if an VM SKU has SGX driver support
+// Tech identifies the confidential computing technology backing a VM size.
+type Tech string
+
+const (
+	// TechSGX marks SKUs that use Intel Software Guard Extensions enclaves.
+	TechSGX Tech = "sgx"
+	// TechSevSnp marks SKUs that use AMD Secure Encrypted Virtualization with
+	// Secure Nested Paging, i.e. confidential VMs.
+	TechSevSnp Tech = "sev-snp"
+)
+
+// sgxEnabledSKUs covers the original DC-series sizes Standard_DC2s and Standard_DC4s, DCsv2
+// (Coffee Lake SGX) and DCsv3/DCdsv3 (Ice Lake SGX with EDMM).
+var sgxEnabledSKUs = map[string]bool{
+	// DCsv2
+	"Standard_DC1s_v2": true,
+	"Standard_DC2s_v2": true,
+	"Standard_DC4s_v2": true,
+	"Standard_DC8_v2":  true,
+	// DC-series
+	"Standard_DC2s": true,
+	"Standard_DC4s": true,
+	// DCsv3 / DCdsv3
+	"Standard_DC1s_v3":   true,
+	"Standard_DC2s_v3":   true,
+	"Standard_DC4s_v3":   true,
+	"Standard_DC8s_v3":   true,
+	"Standard_DC16s_v3":  true,
+	"Standard_DC24s_v3":  true,
+	"Standard_DC32s_v3":  true,
+	"Standard_DC48s_v3":  true,
+	"Standard_DC1ds_v3":  true,
+	"Standard_DC2ds_v3":  true,
+	"Standard_DC4ds_v3":  true,
+	"Standard_DC8ds_v3":  true,
+	"Standard_DC16ds_v3": true,
+	"Standard_DC24ds_v3": true,
+	"Standard_DC32ds_v3": true,
+	"Standard_DC48ds_v3": true,
+}
+
+// sevSnpEnabledSKUs covers the AMD SEV-SNP *_cc_v5 sizes of the DCas, DCads, ECas and ECads
+// families. The plain *_v5 sizes of those families (e.g. Standard_DC2as_v5) are not listed, so
+// IsSevSnpEnabledSKU returns false for them.
+var sevSnpEnabledSKUs = map[string]bool{
+	"Standard_DC2as_cc_v5":   true,
+	"Standard_DC4as_cc_v5":   true,
+	"Standard_DC8as_cc_v5":   true,
+	"Standard_DC16as_cc_v5":  true,
+	"Standard_DC32as_cc_v5":  true,
+	"Standard_DC48as_cc_v5":  true,
+	"Standard_DC64as_cc_v5":  true,
+	"Standard_DC96as_cc_v5":  true,
+	"Standard_DC2ads_cc_v5":  true,
+	"Standard_DC4ads_cc_v5":  true,
+	"Standard_DC8ads_cc_v5":  true,
+	"Standard_DC16ads_cc_v5": true,
+	"Standard_DC32ads_cc_v5": true,
+	"Standard_DC48ads_cc_v5": true,
+	"Standard_DC64ads_cc_v5": true,
+	"Standard_DC96ads_cc_v5": true,
+	"Standard_EC2as_cc_v5":   true,
+	"Standard_EC4as_cc_v5":   true,
+	"Standard_EC8as_cc_v5":   true,
+	"Standard_EC16as_cc_v5":  true,
+	"Standard_EC20as_cc_v5":  true,
+	"Standard_EC32as_cc_v5":  true,
+	"Standard_EC48as_cc_v5":  true,
+	"Standard_EC64as_cc_v5":  true,
+	"Standard_EC96as_cc_v5":  true,
+	"Standard_EC2ads_cc_v5":  true,
+	"Standard_EC4ads_cc_v5":  true,
+	"Standard_EC8ads_cc_v5":  true,
+	"Standard_EC20ads_cc_v5": true,
+	"Standard_EC32ads_cc_v5": true,
+	"Standard_EC48ads_cc_v5": true,
+	"Standard_EC64ads_cc_v5": true,
+	"Standard_EC96ads_cc_v5": true,
+}
+
+// IsSgxEnabledSKU determines if a VM SKU has SGX driver support.
 func IsSgxEnabledSKU(vmSize string) bool {
-	switch vmSize {
-	case "Standard_DC2s", "Standard_DC4s":
-		return true
+	return sgxEnabledSKUs[vmSize]
+}
+
+// IsSevSnpEnabledSKU determines if a VM SKU is an AMD SEV-SNP confidential VM.
+func IsSevSnpEnabledSKU(vmSize string) bool {
+	return sevSnpEnabledSKUs[vmSize]
+}
+
+// GetConfidentialComputingTech returns the confidential computing technology backing vmSize, and
+// false if vmSize is not a confidential computing SKU. Higher-level driver and daemonset
+// decisions should key off this rather than re-deriving it from the SKU name.
+func GetConfidentialComputingTech(vmSize string) (Tech, bool) {
+	switch {
+	case IsSgxEnabledSKU(vmSize):
+		return TechSGX, true
+	case IsSevSnpEnabledSKU(vmSize):
+		return TechSevSnp, true
 	}
-	return false
+	return "", false
 }
 
 // GetStorageAccountType returns the support managed disk storage tier for a give VM size
@@ -165,6 +267,7 @@ func WrapAsVerbatim(s string) string {
 }
 
 // GetDockerConfig transforms the default docker config with overrides. Overrides may be nil.
+// Callers that need Stage-ordered overrides or a DryRun should prefer BuildDockerConfig.
 func GetDockerConfig(opts map[string]string, overrides []func(*DockerConfig) error) (string, error) {
 	config := GetDefaultDockerConfig()
 
@@ -183,7 +286,107 @@ func GetDockerConfig(opts map[string]string, overrides []func(*DockerConfig) err
 	return string(b), err
 }
 
-// GetContainerdConfig transforms the default containerd config with overrides. Overrides may be nil.
+// DockerOverride is a named, orderable transform in the docker config assembly pipeline, the
+// docker-path equivalent of Override.
+type DockerOverride struct {
+	// Name must be unique among the overrides passed to a single BuildDockerConfig call.
+	Name string
+	// Stage controls coarse ordering; see the Stage* constants.
+	Stage Stage
+	// Requires lists the Names of overrides that must apply before this one.
+	Requires []string
+	Apply    func(*DockerConfig) error
+}
+
+// BuildDockerConfig is the docker-path equivalent of BuildContainerdConfig: it topologically
+// sorts overrides by Stage and then by Requires, validates there are no duplicate Names or
+// unresolved/circular dependencies, applies them in that order, and returns the resulting JSON
+// alongside an execution-order trace of the override Names that ran. If opts.DryRun is set, the
+// JSON string is empty and the resolved config should be read from the returned *DockerConfig
+// instead.
+func BuildDockerConfig(opts BuildOptions, overrides ...DockerOverride) (string, *DockerConfig, []string, error) {
+	ordered, err := sortDockerOverrides(overrides)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	config := GetDefaultDockerConfig()
+	trace := make([]string, 0, len(ordered))
+	for _, o := range ordered {
+		if err := o.Apply(&config); err != nil {
+			return "", nil, nil, errors.Wrapf(err, "applying override %q", o.Name)
+		}
+		trace = append(trace, o.Name)
+	}
+
+	if dataDir, ok := opts.Opts[ContainerDataDirKey]; ok {
+		config.DataRoot = dataDir
+	}
+
+	if opts.DryRun {
+		return "", &config, trace, nil
+	}
+
+	b, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return "", nil, trace, err
+	}
+	return string(b), &config, trace, nil
+}
+
+// sortDockerOverrides is the DockerOverride equivalent of sortOverrides.
+func sortDockerOverrides(overrides []DockerOverride) ([]DockerOverride, error) {
+	byName := make(map[string]DockerOverride, len(overrides))
+	for _, o := range overrides {
+		if _, dup := byName[o.Name]; dup {
+			return nil, errors.Errorf("duplicate override name %q", o.Name)
+		}
+		byName[o.Name] = o
+	}
+
+	bySequence := make([]DockerOverride, len(overrides))
+	copy(bySequence, overrides)
+	sort.SliceStable(bySequence, func(i, j int) bool { return bySequence[i].Stage < bySequence[j].Stage })
+
+	visited := make(map[string]bool, len(bySequence))
+	resolved := make([]DockerOverride, 0, len(bySequence))
+
+	var visit func(o DockerOverride, stack map[string]bool) error
+	visit = func(o DockerOverride, stack map[string]bool) error {
+		if visited[o.Name] {
+			return nil
+		}
+		if stack[o.Name] {
+			return errors.Errorf("circular Requires dependency involving override %q", o.Name)
+		}
+		stack[o.Name] = true
+		for _, dep := range o.Requires {
+			depOverride, ok := byName[dep]
+			if !ok {
+				return errors.Errorf("override %q requires unknown override %q", o.Name, dep)
+			}
+			if err := visit(depOverride, stack); err != nil {
+				return err
+			}
+		}
+		delete(stack, o.Name)
+		visited[o.Name] = true
+		resolved = append(resolved, o)
+		return nil
+	}
+
+	for _, o := range bySequence {
+		if err := visit(o, map[string]bool{}); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// GetContainerdConfig transforms the default containerd config with overrides. Overrides may be
+// nil. Callers that need more than one or two overrides, or that need Nvidia/Kubenet/registry
+// overrides to run in a specific order relative to each other, should prefer BuildContainerdConfig,
+// which sorts overrides for you instead of depending on slice order.
 func GetContainerdConfig(opts map[string]string, overrides []func(*ContainerdConfig) error) (string, error) {
 	config := GetDefaultContainerdConfig()
 
@@ -203,6 +406,189 @@ func GetContainerdConfig(opts map[string]string, overrides []func(*ContainerdCon
 	return buf.String(), err
 }
 
+// Stage names a point in the containerd config assembly pipeline. Overrides passed to
+// BuildContainerdConfig are grouped and applied in Stage order, so e.g. a registry override
+// always runs after the runtimes are registered, without callers having to interleave closures
+// in the right order by hand.
+type Stage int
+
+// Fixed stage order for BuildContainerdConfig. Runtimes must exist before a default can be
+// selected from among them (Runtimes), registry mirrors/auth are independent of runtime choice
+// but must land before general CRI settings that might reference a registry (Registry -> CRI),
+// and Plugins/Final are left for overrides with no natural earlier home.
+const (
+	StageBase Stage = iota
+	StageRuntimes
+	StageRegistry
+	StageCRI
+	StagePlugins
+	StageFinal
+)
+
+// Override is a named, orderable transform in the containerd config assembly pipeline. It
+// replaces the ad-hoc practice of relying on callers to pass []func(*ContainerdConfig) error in
+// the right order by hand.
+type Override struct {
+	// Name must be unique among the overrides passed to a single BuildContainerdConfig call.
+	Name string
+	// Stage controls coarse ordering; see the Stage* constants.
+	Stage Stage
+	// Requires lists the Names of overrides that must apply before this one, for fine-grained
+	// ordering within (or across) a Stage.
+	Requires []string
+	Apply    func(*ContainerdConfig) error
+}
+
+// BuildOptions controls BuildContainerdConfig's output.
+type BuildOptions struct {
+	// Opts carries the same loose string options GetContainerdConfig accepts, e.g. ContainerDataDirKey.
+	Opts map[string]string
+	// DryRun, when true, skips TOML marshaling so callers such as VHD tests or e2e golden-file
+	// tests can assert directly on the resolved *ContainerdConfig.
+	DryRun bool
+}
+
+// BuildContainerdConfig topologically sorts overrides by Stage and then by Requires, validates
+// there are no duplicate Names or unresolved/circular dependencies, applies them in that order,
+// and returns the resulting TOML alongside an execution-order trace of the override Names that
+// ran (not a field-level diagnostic). If opts.DryRun is set, the TOML string is empty and the
+// resolved config should be read from the returned *ContainerdConfig instead.
+func BuildContainerdConfig(opts BuildOptions, overrides ...Override) (string, *ContainerdConfig, []string, error) {
+	ordered, err := sortOverrides(overrides)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	config := GetDefaultContainerdConfig()
+	trace := make([]string, 0, len(ordered))
+	for _, o := range ordered {
+		if err := o.Apply(&config); err != nil {
+			return "", nil, nil, errors.Wrapf(err, "applying override %q", o.Name)
+		}
+		trace = append(trace, o.Name)
+	}
+
+	if dataDir, ok := opts.Opts[ContainerDataDirKey]; ok {
+		config.Root = dataDir
+	}
+
+	if opts.DryRun {
+		return "", &config, trace, nil
+	}
+
+	buf := new(bytes.Buffer)
+	if err := toml.NewEncoder(buf).Encode(config); err != nil {
+		return "", nil, trace, err
+	}
+	return buf.String(), &config, trace, nil
+}
+
+// sortOverrides validates overrides for duplicate names and unresolved Requires, and returns them
+// ordered by Stage, then topologically by Requires within and across stages.
+func sortOverrides(overrides []Override) ([]Override, error) {
+	byName := make(map[string]Override, len(overrides))
+	for _, o := range overrides {
+		if _, dup := byName[o.Name]; dup {
+			return nil, errors.Errorf("duplicate override name %q", o.Name)
+		}
+		byName[o.Name] = o
+	}
+
+	bySequence := make([]Override, len(overrides))
+	copy(bySequence, overrides)
+	sort.SliceStable(bySequence, func(i, j int) bool { return bySequence[i].Stage < bySequence[j].Stage })
+
+	visited := make(map[string]bool, len(bySequence))
+	resolved := make([]Override, 0, len(bySequence))
+
+	var visit func(o Override, stack map[string]bool) error
+	visit = func(o Override, stack map[string]bool) error {
+		if visited[o.Name] {
+			return nil
+		}
+		if stack[o.Name] {
+			return errors.Errorf("circular Requires dependency involving override %q", o.Name)
+		}
+		stack[o.Name] = true
+		for _, dep := range o.Requires {
+			depOverride, ok := byName[dep]
+			if !ok {
+				return errors.Errorf("override %q requires unknown override %q", o.Name, dep)
+			}
+			if err := visit(depOverride, stack); err != nil {
+				return err
+			}
+		}
+		delete(stack, o.Name)
+		visited[o.Name] = true
+		resolved = append(resolved, o)
+		return nil
+	}
+
+	for _, o := range bySequence {
+		if err := visit(o, map[string]bool{}); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// NewKubenetOverride returns the Override wrapping ContainerdKubenetOverride for use with BuildContainerdConfig.
+func NewKubenetOverride() Override {
+	return Override{Name: "kubenet", Stage: StageCRI, Apply: ContainerdKubenetOverride}
+}
+
+// NewSandboxImageOverride returns the Override wrapping ContainerdSandboxImageOverrider for use with BuildContainerdConfig.
+func NewSandboxImageOverride(image string) Override {
+	return Override{Name: "sandbox-image", Stage: StageCRI, Apply: ContainerdSandboxImageOverrider(image)}
+}
+
+// NewRuntimeOverride returns the Override wrapping ContainerdAddRuntime for use with BuildContainerdConfig.
+func NewRuntimeOverride(name, runtimeType, binaryPath string, opts map[string]interface{}) Override {
+	return Override{Name: "runtime:" + name, Stage: StageRuntimes, Apply: ContainerdAddRuntime(name, runtimeType, binaryPath, opts)}
+}
+
+// NewDefaultRuntimeOverride returns the Override wrapping ContainerdSetDefaultRuntime, requiring
+// that name's NewRuntimeOverride has already run.
+func NewDefaultRuntimeOverride(name string) Override {
+	return Override{
+		Name:     "default-runtime:" + name,
+		Stage:    StageRuntimes,
+		Requires: []string{"runtime:" + name},
+		Apply:    ContainerdSetDefaultRuntime(name),
+	}
+}
+
+// NewNvidiaRuntimeOverride returns the Override wrapping ContainerdNvidiaOverride for use with BuildContainerdConfig.
+func NewNvidiaRuntimeOverride() Override {
+	return Override{Name: "runtime:nvidia", Stage: StageRuntimes, Apply: ContainerdNvidiaOverride}
+}
+
+// NewKataCCRuntimeOverride returns the Override wrapping ContainerdKataCCOverride for use with BuildContainerdConfig.
+func NewKataCCRuntimeOverride(tech Tech) Override {
+	return Override{Name: "runtime:kata-cc", Stage: StageRuntimes, Apply: ContainerdKataCCOverride(tech)}
+}
+
+// NewCDIOverride returns the Override wrapping ContainerdCDIOverride for use with BuildContainerdConfig.
+func NewCDIOverride() Override {
+	return Override{Name: "cdi", Stage: StagePlugins, Apply: ContainerdCDIOverride}
+}
+
+// NewRegistryMirrorOverride returns the Override wrapping ContainerdRegistryMirrorOverride for use with BuildContainerdConfig.
+func NewRegistryMirrorOverride(mirrors map[string][]string) Override {
+	return Override{Name: "registry-mirrors", Stage: StageRegistry, Apply: ContainerdRegistryMirrorOverride(mirrors)}
+}
+
+// NewRegistryAuthOverride returns the Override wrapping ContainerdRegistryAuthOverride for use with BuildContainerdConfig.
+func NewRegistryAuthOverride(creds map[string]RegistryAuth) Override {
+	return Override{Name: "registry-auth", Stage: StageRegistry, Apply: ContainerdRegistryAuthOverride(creds)}
+}
+
+// NewRegistryConfigPathOverride returns the Override wrapping ContainerdRegistryConfigPathOverride for use with BuildContainerdConfig.
+func NewRegistryConfigPathOverride() Override {
+	return Override{Name: "registry-config-path", Stage: StageRegistry, Apply: ContainerdRegistryConfigPathOverride}
+}
+
 // ContainerdKubenetOverride transforms a containerd config to set details required when using kubenet.
 func ContainerdKubenetOverride(config *ContainerdConfig) error {
 	config.Plugins.IoContainerdGrpcV1Cri.CNI.ConfTemplate = "/etc/containerd/kubenet_template.conf"
@@ -217,6 +603,212 @@ func ContainerdSandboxImageOverrider(image string) func(*ContainerdConfig) error
 	}
 }
 
+// ContainerdRegistryMirror mirrors plugins."io.containerd.grpc.v1.cri".registry.mirrors.<host>,
+// the ordered list of endpoints the CRI plugin tries before falling back to the host itself.
+type ContainerdRegistryMirror struct {
+	Endpoint []string `toml:"endpoint"`
+}
+
+// ContainerdRegistryHostConfig mirrors plugins."io.containerd.grpc.v1.cri".registry.configs.<host>.
+type ContainerdRegistryHostConfig struct {
+	Auth RegistryAuth `toml:"auth"`
+}
+
+// RegistryAuth carries the credentials containerd should present to a private registry host,
+// mirroring plugins."io.containerd.grpc.v1.cri".registry.configs.<host>.auth. Auth and
+// IdentityToken are alternatives to Username/Password; set whichever pair the registry expects.
+type RegistryAuth struct {
+	Username      string `toml:"username,omitempty"`
+	Password      string `toml:"password,omitempty"`
+	Auth          string `toml:"auth,omitempty"`
+	IdentityToken string `toml:"identitytoken,omitempty"`
+}
+
+// ContainerdRegistryMirrorOverride produces a function to transform a containerd config by
+// registering, for each host in mirrors, the ordered list of endpoints the CRI plugin should try first.
+func ContainerdRegistryMirrorOverride(mirrors map[string][]string) func(*ContainerdConfig) error {
+	return func(config *ContainerdConfig) error {
+		if config.Plugins.IoContainerdGrpcV1Cri.Registry.Mirrors == nil {
+			config.Plugins.IoContainerdGrpcV1Cri.Registry.Mirrors = make(map[string]ContainerdRegistryMirror)
+		}
+		for host, endpoints := range mirrors {
+			config.Plugins.IoContainerdGrpcV1Cri.Registry.Mirrors[host] = ContainerdRegistryMirror{
+				Endpoint: endpoints,
+			}
+		}
+		return nil
+	}
+}
+
+// ContainerdRegistryAuthOverride produces a function to transform a containerd config by
+// registering per-host credentials for private registries.
+func ContainerdRegistryAuthOverride(creds map[string]RegistryAuth) func(*ContainerdConfig) error {
+	return func(config *ContainerdConfig) error {
+		if config.Plugins.IoContainerdGrpcV1Cri.Registry.Configs == nil {
+			config.Plugins.IoContainerdGrpcV1Cri.Registry.Configs = make(map[string]ContainerdRegistryHostConfig)
+		}
+		for host, auth := range creds {
+			config.Plugins.IoContainerdGrpcV1Cri.Registry.Configs[host] = ContainerdRegistryHostConfig{Auth: auth}
+		}
+		return nil
+	}
+}
+
+// ContainerdRegistryConfigPathOverride transforms a containerd config to use the newer
+// certs.d-style host configuration directory instead of the legacy registry.mirrors/registry.configs
+// tables. Callers that set this should render the corresponding hosts.toml files with
+// RenderHostsToml alongside the main config.
+func ContainerdRegistryConfigPathOverride(config *ContainerdConfig) error {
+	config.Plugins.IoContainerdGrpcV1Cri.Registry.ConfigPath = "/etc/containerd/certs.d"
+	return nil
+}
+
+// RenderHostsToml renders the per-host hosts.toml contents expected under
+// /etc/containerd/certs.d/<host>/hosts.toml when ContainerdRegistryConfigPathOverride is in use.
+func RenderHostsToml(host string, mirrors []string) (string, error) {
+	type hostEntry struct {
+		Capabilities []string `toml:"capabilities"`
+	}
+	type hostsFile struct {
+		Server string               `toml:"server"`
+		Host   map[string]hostEntry `toml:"host"`
+	}
+
+	f := hostsFile{
+		Server: fmt.Sprintf("https://%s", host),
+		Host:   make(map[string]hostEntry, len(mirrors)),
+	}
+	for _, mirror := range mirrors {
+		f.Host[mirror] = hostEntry{Capabilities: []string{"pull", "resolve"}}
+	}
+
+	buf := new(bytes.Buffer)
+	err := toml.NewEncoder(buf).Encode(f)
+	return buf.String(), err
+}
+
+// Runtime type identifiers understood by containerd's CRI plugin. These are the values expected
+// in plugins."io.containerd.grpc.v1.cri".containerd.runtimes.<name>.runtime_type.
+const (
+	ContainerdRuntimeTypeRunc   = "io.containerd.runc.v2"
+	ContainerdRuntimeTypeKata   = "io.containerd.kata.v2"
+	ContainerdRuntimeTypeGvisor = "io.containerd.runsc.v1"
+)
+
+// ContainerdRuntimeOptions carries the per-runtime "options" table, e.g. BinaryName,
+// SystemdCgroup, or ConfigPath, the set of which varies by RuntimeType.
+type ContainerdRuntimeOptions map[string]interface{}
+
+// ContainerdRuntime mirrors a single entry under
+// plugins."io.containerd.grpc.v1.cri".containerd.runtimes.<name>, letting a node register more
+// than one OCI runtime so pods can be scheduled onto Kata, gVisor, or NVIDIA sandboxes side-by-side
+// via a Kubernetes RuntimeClass.
+type ContainerdRuntime struct {
+	RuntimeType                  string                   `toml:"runtime_type"`
+	PrivilegedWithoutHostDevices bool                     `toml:"privileged_without_host_devices,omitempty"`
+	Options                      ContainerdRuntimeOptions `toml:"options,omitempty"`
+}
+
+// containerdRuntimeOptPrivileged is a reserved opts key for ContainerdAddRuntime: when present it
+// sets ContainerdRuntime.PrivilegedWithoutHostDevices instead of being copied into Options.
+const containerdRuntimeOptPrivileged = "PrivilegedWithoutHostDevices"
+
+// ContainerdAddRuntime produces a function to transform a containerd config by registering an
+// additional runtime under plugins."io.containerd.grpc.v1.cri".containerd.runtimes.<name>. opts is
+// merged into the runtime's options table (e.g. SystemdCgroup, ConfigPath); binaryPath, when set,
+// is populated as the options table's BinaryName. A bool opts["PrivilegedWithoutHostDevices"] sets
+// the runtime's privileged_without_host_devices flag rather than being added to Options.
+func ContainerdAddRuntime(name, runtimeType, binaryPath string, opts map[string]interface{}) func(*ContainerdConfig) error {
+	return func(config *ContainerdConfig) error {
+		if name == "" {
+			return errors.New("runtime name must not be empty")
+		}
+		if config.Plugins.IoContainerdGrpcV1Cri.Containerd.Runtimes == nil {
+			config.Plugins.IoContainerdGrpcV1Cri.Containerd.Runtimes = make(map[string]ContainerdRuntime)
+		}
+
+		var privileged bool
+		options := ContainerdRuntimeOptions{}
+		for k, v := range opts {
+			if k == containerdRuntimeOptPrivileged {
+				p, ok := v.(bool)
+				if !ok {
+					return errors.Errorf("opts[%q] must be a bool, got %T", containerdRuntimeOptPrivileged, v)
+				}
+				privileged = p
+				continue
+			}
+			options[k] = v
+		}
+		if binaryPath != "" {
+			options["BinaryName"] = binaryPath
+		}
+
+		config.Plugins.IoContainerdGrpcV1Cri.Containerd.Runtimes[name] = ContainerdRuntime{
+			RuntimeType:                  runtimeType,
+			PrivilegedWithoutHostDevices: privileged,
+			Options:                      options,
+		}
+		return nil
+	}
+}
+
+// ContainerdSetDefaultRuntime produces a function to transform a containerd config by setting
+// the default_runtime_name used for pods that do not request a RuntimeClass. name must already
+// have been registered via ContainerdAddRuntime.
+func ContainerdSetDefaultRuntime(name string) func(*ContainerdConfig) error {
+	return func(config *ContainerdConfig) error {
+		if _, ok := config.Plugins.IoContainerdGrpcV1Cri.Containerd.Runtimes[name]; !ok {
+			return errors.Errorf("cannot set default runtime to %q: no such runtime has been registered", name)
+		}
+		config.Plugins.IoContainerdGrpcV1Cri.Containerd.DefaultRuntimeName = name
+		return nil
+	}
+}
+
+// ContainerdNvidiaOverride transforms a containerd config to register the nvidia-container-runtime.
+func ContainerdNvidiaOverride(config *ContainerdConfig) error {
+	return ContainerdAddRuntime("nvidia", ContainerdRuntimeTypeRunc, "/usr/bin/nvidia-container-runtime", nil)(config)
+}
+
+// kataCCConfigPaths maps a confidential computing Tech to the kata-containers configuration.toml
+// it should run with. Only SEV-SNP is populated; no confirmed upstream kata-cc config exists yet
+// for SGX, so ContainerdKataCCOverride errors out for TechSGX rather than guess a path.
+var kataCCConfigPaths = map[Tech]string{
+	TechSevSnp: "/opt/confidential-containers/share/defaults/kata-containers/configuration-snp.toml",
+}
+
+// ContainerdKataCCOverride produces a function to transform a containerd config by registering a
+// kata-cc runtime configured for the given confidential computing Tech.
+func ContainerdKataCCOverride(tech Tech) func(*ContainerdConfig) error {
+	return func(config *ContainerdConfig) error {
+		configPath, ok := kataCCConfigPaths[tech]
+		if !ok {
+			return errors.Errorf("kata-cc is not supported for confidential computing tech %q", tech)
+		}
+		return ContainerdAddRuntime("kata-cc", ContainerdRuntimeTypeKata, "", map[string]interface{}{
+			"ConfigPath": configPath,
+		})(config)
+	}
+}
+
+// sgxDevices are the device nodes the SGX kernel driver exposes for enclave creation and
+// provisioning.
+var sgxDevices = []string{"/dev/sgx_enclave", "/dev/sgx_provision"}
+
+// DockerSgxDevicePluginOverride transforms a docker config to expose the SGX device nodes to containers.
+func DockerSgxDevicePluginOverride(config *DockerConfig) error {
+	config.Devices = append(config.Devices, sgxDevices...)
+	return nil
+}
+
+// ContainerdSgxDevicePluginOverride transforms a containerd config to expose the SGX device nodes
+// via the CRI plugin's device list.
+func ContainerdSgxDevicePluginOverride(config *ContainerdConfig) error {
+	config.Plugins.IoContainerdGrpcV1Cri.Devices = append(config.Plugins.IoContainerdGrpcV1Cri.Devices, sgxDevices...)
+	return nil
+}
+
 // DockerNvidiaOverride transforms a docker config to supply nvidia runtime configuration.
 func DockerNvidiaOverride(config *DockerConfig) error {
 	if config.DockerDaemonRuntimes == nil {
@@ -230,6 +822,54 @@ func DockerNvidiaOverride(config *DockerConfig) error {
 	return nil
 }
 
+// cdiSpecDirs are the directories the CRI plugin and the docker CDI feature scan for CDI JSON,
+// in precedence order. Dropping additional vendor CDI specs (AMD, Intel Gaudi, ...) into either
+// directory plugs them into the same discovery path NVIDIA uses.
+var cdiSpecDirs = []string{"/etc/cdi", "/var/run/cdi"}
+
+// ContainerdCDIOverride transforms a containerd config to enable the Container Device Interface.
+func ContainerdCDIOverride(config *ContainerdConfig) error {
+	config.Plugins.IoContainerdGrpcV1Cri.EnableCDI = true
+	config.Plugins.IoContainerdGrpcV1Cri.CDISpecDirs = cdiSpecDirs
+	return nil
+}
+
+// DockerCDIOverride transforms a docker config to enable the Container Device Interface feature,
+// for daemons new enough to support --feature=cdi.
+func DockerCDIOverride(config *DockerConfig) error {
+	if config.Features == nil {
+		config.Features = make(map[string]bool)
+	}
+	config.Features["cdi"] = true
+	return nil
+}
+
+// NvidiaMIGEnabledSKUs lists the Nvidia GPU SKUs that expose MIG (Multi-Instance
+// GPU) partitions rather than a single whole-GPU device, keyed to the MIG profile AgentBaker
+// advertises by default.
+var NvidiaMIGEnabledSKUs = map[string]string{
+	"Standard_NC24ads_A100_v4":  "nvidia.com/mig-1g.5gb",
+	"Standard_NC48ads_A100_v4":  "nvidia.com/mig-1g.5gb",
+	"Standard_NC96ads_A100_v4":  "nvidia.com/mig-1g.5gb",
+	"Standard_ND96asr_v4":       "nvidia.com/mig-1g.5gb",
+	"Standard_NC40ads_H100_v5":  "nvidia.com/mig-1g.5gb",
+	"Standard_NC80adis_H100_v5": "nvidia.com/mig-1g.5gb",
+}
+
+// GetNvidiaCDIDeviceClass returns the CDI device class AgentBaker should request for vmSize: the
+// MIG profile for SKUs that partition their GPU, or the catch-all "nvidia.com/gpu=all" for SKUs
+// that expose whole GPUs. It returns false for any vmSize that is not Nvidia-enabled.
+func GetNvidiaCDIDeviceClass(vmSize string) (string, bool) {
+	if !IsNvidiaEnabledSKU(vmSize) {
+		return "", false
+	}
+	vmSize = strings.TrimSuffix(vmSize, "_Promo")
+	if class, ok := NvidiaMIGEnabledSKUs[vmSize]; ok {
+		return fmt.Sprintf("%s=all", class), true
+	}
+	return "nvidia.com/gpu=all", true
+}
+
 // IndentString pads each line of an original string with N spaces and returns the new value.
 func IndentString(original string, spaces int) string {
 	out := bytes.NewBuffer(nil)
@@ -0,0 +1,327 @@
+// Copyright (c) Microsoft Corporation. All rights reserved.
+// Licensed under the MIT license.
+
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func noopApply(*ContainerdConfig) error { return nil }
+
+func TestSortOverridesDuplicateName(t *testing.T) {
+	_, err := sortOverrides([]Override{
+		{Name: "dup", Stage: StageBase, Apply: noopApply},
+		{Name: "dup", Stage: StageFinal, Apply: noopApply},
+	})
+	if err == nil {
+		t.Fatal("expected an error for a duplicate override name, got nil")
+	}
+}
+
+func TestSortOverridesUnknownRequires(t *testing.T) {
+	_, err := sortOverrides([]Override{
+		{Name: "a", Stage: StageBase, Requires: []string{"missing"}, Apply: noopApply},
+	})
+	if err == nil {
+		t.Fatal("expected an error for a Requires on an unknown override, got nil")
+	}
+}
+
+func TestSortOverridesCycle(t *testing.T) {
+	_, err := sortOverrides([]Override{
+		{Name: "a", Stage: StageBase, Requires: []string{"b"}, Apply: noopApply},
+		{Name: "b", Stage: StageBase, Requires: []string{"a"}, Apply: noopApply},
+	})
+	if err == nil {
+		t.Fatal("expected an error for a circular Requires dependency, got nil")
+	}
+}
+
+func TestSortOverridesRequiresReordersAcrossStages(t *testing.T) {
+	ordered, err := sortOverrides([]Override{
+		{Name: "early-stage-late-deps", Stage: StageBase, Requires: []string{"late-stage-no-deps"}, Apply: noopApply},
+		{Name: "late-stage-no-deps", Stage: StageFinal, Apply: noopApply},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ordered) != 2 || ordered[0].Name != "late-stage-no-deps" || ordered[1].Name != "early-stage-late-deps" {
+		t.Fatalf("expected Requires to pull the dependency ahead of its Stage order, got %+v", ordered)
+	}
+}
+
+func TestContainerdAddRuntime(t *testing.T) {
+	config := &ContainerdConfig{}
+	err := ContainerdAddRuntime("kata", ContainerdRuntimeTypeKata, "/usr/bin/kata-runtime", map[string]interface{}{
+		"SystemdCgroup": true,
+	})(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	runtime, ok := config.Plugins.IoContainerdGrpcV1Cri.Containerd.Runtimes["kata"]
+	if !ok {
+		t.Fatal("expected a runtime named kata to be registered")
+	}
+	if runtime.RuntimeType != ContainerdRuntimeTypeKata {
+		t.Fatalf("expected RuntimeType %q, got %q", ContainerdRuntimeTypeKata, runtime.RuntimeType)
+	}
+	if runtime.Options["BinaryName"] != "/usr/bin/kata-runtime" {
+		t.Fatalf("expected BinaryName to be set from binaryPath, got %v", runtime.Options["BinaryName"])
+	}
+	if runtime.Options["SystemdCgroup"] != true {
+		t.Fatalf("expected SystemdCgroup to be carried through from opts, got %v", runtime.Options["SystemdCgroup"])
+	}
+	if runtime.PrivilegedWithoutHostDevices {
+		t.Fatal("expected PrivilegedWithoutHostDevices to default to false")
+	}
+}
+
+func TestContainerdAddRuntimePrivilegedWithoutHostDevices(t *testing.T) {
+	config := &ContainerdConfig{}
+	err := ContainerdAddRuntime("gvisor", ContainerdRuntimeTypeGvisor, "", map[string]interface{}{
+		containerdRuntimeOptPrivileged: true,
+	})(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	runtime := config.Plugins.IoContainerdGrpcV1Cri.Containerd.Runtimes["gvisor"]
+	if !runtime.PrivilegedWithoutHostDevices {
+		t.Fatal("expected PrivilegedWithoutHostDevices to be true")
+	}
+	if _, ok := runtime.Options[containerdRuntimeOptPrivileged]; ok {
+		t.Fatal("expected the reserved opts key not to leak into Options")
+	}
+}
+
+func TestContainerdAddRuntimePrivilegedWithoutHostDevicesWrongType(t *testing.T) {
+	config := &ContainerdConfig{}
+	err := ContainerdAddRuntime("gvisor", ContainerdRuntimeTypeGvisor, "", map[string]interface{}{
+		containerdRuntimeOptPrivileged: "true",
+	})(config)
+	if err == nil {
+		t.Fatal("expected an error when opts[PrivilegedWithoutHostDevices] is not a bool")
+	}
+}
+
+func TestContainerdSetDefaultRuntime(t *testing.T) {
+	config := &ContainerdConfig{}
+	if err := ContainerdSetDefaultRuntime("nvidia")(config); err == nil {
+		t.Fatal("expected an error when setting a default runtime that was never registered")
+	}
+
+	if err := ContainerdNvidiaOverride(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ContainerdSetDefaultRuntime("nvidia")(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.Plugins.IoContainerdGrpcV1Cri.Containerd.DefaultRuntimeName != "nvidia" {
+		t.Fatalf("expected default runtime name nvidia, got %q", config.Plugins.IoContainerdGrpcV1Cri.Containerd.DefaultRuntimeName)
+	}
+}
+
+func TestContainerdNvidiaOverride(t *testing.T) {
+	config := &ContainerdConfig{}
+	if err := ContainerdNvidiaOverride(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	runtime, ok := config.Plugins.IoContainerdGrpcV1Cri.Containerd.Runtimes["nvidia"]
+	if !ok {
+		t.Fatal("expected a runtime named nvidia to be registered")
+	}
+	if runtime.Options["BinaryName"] != "/usr/bin/nvidia-container-runtime" {
+		t.Fatalf("expected nvidia-container-runtime binary, got %v", runtime.Options["BinaryName"])
+	}
+}
+
+func TestContainerdRegistryMirrorOverride(t *testing.T) {
+	config := &ContainerdConfig{}
+	err := ContainerdRegistryMirrorOverride(map[string][]string{
+		"mcr.microsoft.com": {"https://mcr-mirror.example.com"},
+	})(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mirror, ok := config.Plugins.IoContainerdGrpcV1Cri.Registry.Mirrors["mcr.microsoft.com"]
+	if !ok {
+		t.Fatal("expected a mirror entry for mcr.microsoft.com")
+	}
+	if len(mirror.Endpoint) != 1 || mirror.Endpoint[0] != "https://mcr-mirror.example.com" {
+		t.Fatalf("expected the configured endpoint, got %v", mirror.Endpoint)
+	}
+}
+
+func TestContainerdRegistryAuthOverride(t *testing.T) {
+	config := &ContainerdConfig{}
+	err := ContainerdRegistryAuthOverride(map[string]RegistryAuth{
+		"myregistry.azurecr.io": {Username: "user", Password: "pass"},
+	})(config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	hostConfig, ok := config.Plugins.IoContainerdGrpcV1Cri.Registry.Configs["myregistry.azurecr.io"]
+	if !ok {
+		t.Fatal("expected a registry config entry for myregistry.azurecr.io")
+	}
+	if hostConfig.Auth.Username != "user" || hostConfig.Auth.Password != "pass" {
+		t.Fatalf("expected the configured credentials, got %+v", hostConfig.Auth)
+	}
+}
+
+func TestRenderHostsToml(t *testing.T) {
+	out, err := RenderHostsToml("mcr.microsoft.com", []string{"https://mcr-mirror.example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, `server = "https://mcr.microsoft.com"`) {
+		t.Fatalf("expected the rendered hosts.toml to reference the server, got %q", out)
+	}
+	if !strings.Contains(out, "https://mcr-mirror.example.com") {
+		t.Fatalf("expected the rendered hosts.toml to reference the mirror, got %q", out)
+	}
+}
+
+func TestIsSgxEnabledSKU(t *testing.T) {
+	for _, vmSize := range []string{"Standard_DC2s", "Standard_DC2s_v2", "Standard_DC2s_v3", "Standard_DC2ds_v3"} {
+		if !IsSgxEnabledSKU(vmSize) {
+			t.Errorf("expected %s to be SGX-enabled", vmSize)
+		}
+	}
+	if IsSgxEnabledSKU("Standard_D2s_v3") {
+		t.Error("expected Standard_D2s_v3 to not be SGX-enabled")
+	}
+}
+
+func TestIsSevSnpEnabledSKU(t *testing.T) {
+	for _, vmSize := range []string{"Standard_DC8as_cc_v5", "Standard_EC8as_cc_v5", "Standard_DC8ads_cc_v5"} {
+		if !IsSevSnpEnabledSKU(vmSize) {
+			t.Errorf("expected %s to be SEV-SNP-enabled", vmSize)
+		}
+	}
+	if IsSevSnpEnabledSKU("Standard_D2s_v3") {
+		t.Error("expected Standard_D2s_v3 to not be SEV-SNP-enabled")
+	}
+}
+
+func TestGetConfidentialComputingTech(t *testing.T) {
+	tech, ok := GetConfidentialComputingTech("Standard_DC2s_v3")
+	if !ok || tech != TechSGX {
+		t.Fatalf("expected (TechSGX, true), got (%v, %v)", tech, ok)
+	}
+
+	tech, ok = GetConfidentialComputingTech("Standard_DC8as_cc_v5")
+	if !ok || tech != TechSevSnp {
+		t.Fatalf("expected (TechSevSnp, true), got (%v, %v)", tech, ok)
+	}
+
+	if _, ok := GetConfidentialComputingTech("Standard_D2s_v3"); ok {
+		t.Fatal("expected a non-confidential-computing SKU to return false")
+	}
+}
+
+func TestContainerdKataCCOverride(t *testing.T) {
+	config := &ContainerdConfig{}
+	if err := ContainerdKataCCOverride(TechSevSnp)(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	runtime, ok := config.Plugins.IoContainerdGrpcV1Cri.Containerd.Runtimes["kata-cc"]
+	if !ok {
+		t.Fatal("expected a runtime named kata-cc to be registered")
+	}
+	if runtime.Options["ConfigPath"] != kataCCConfigPaths[TechSevSnp] {
+		t.Fatalf("expected the SEV-SNP kata-cc config path, got %v", runtime.Options["ConfigPath"])
+	}
+
+	if err := ContainerdKataCCOverride(TechSGX)(&ContainerdConfig{}); err == nil {
+		t.Fatal("expected an error for TechSGX, which has no confirmed kata-cc config")
+	}
+}
+
+func TestSgxDevicePluginOverrides(t *testing.T) {
+	dockerConfig := &DockerConfig{}
+	if err := DockerSgxDevicePluginOverride(dockerConfig); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(dockerConfig.Devices) != 2 {
+		t.Fatalf("expected 2 SGX devices, got %v", dockerConfig.Devices)
+	}
+
+	containerdConfig := &ContainerdConfig{}
+	if err := ContainerdSgxDevicePluginOverride(containerdConfig); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(containerdConfig.Plugins.IoContainerdGrpcV1Cri.Devices) != 2 {
+		t.Fatalf("expected 2 SGX devices, got %v", containerdConfig.Plugins.IoContainerdGrpcV1Cri.Devices)
+	}
+}
+
+func TestGetNvidiaCDIDeviceClassMIGSku(t *testing.T) {
+	class, ok := GetNvidiaCDIDeviceClass("Standard_NC24ads_A100_v4")
+	if !ok {
+		t.Fatal("expected Standard_NC24ads_A100_v4 to be Nvidia-enabled")
+	}
+	if class != "nvidia.com/mig-1g.5gb=all" {
+		t.Fatalf("expected MIG device class, got %q", class)
+	}
+
+	class, ok = GetNvidiaCDIDeviceClass("Standard_NC40ads_H100_v5")
+	if !ok {
+		t.Fatal("expected Standard_NC40ads_H100_v5 to be Nvidia-enabled")
+	}
+	if class != "nvidia.com/mig-1g.5gb=all" {
+		t.Fatalf("expected MIG device class, got %q", class)
+	}
+}
+
+func TestGetNvidiaCDIDeviceClassWholeGpuSku(t *testing.T) {
+	class, ok := GetNvidiaCDIDeviceClass("Standard_NC6")
+	if !ok {
+		t.Fatal("expected Standard_NC6 to be Nvidia-enabled")
+	}
+	if class != "nvidia.com/gpu=all" {
+		t.Fatalf("expected whole-GPU device class, got %q", class)
+	}
+}
+
+func TestGetNvidiaCDIDeviceClassNonGpuSku(t *testing.T) {
+	if _, ok := GetNvidiaCDIDeviceClass("Standard_D2s_v3"); ok {
+		t.Fatal("expected Standard_D2s_v3 to not be Nvidia-enabled")
+	}
+}
+
+func TestBuildDockerConfigDryRun(t *testing.T) {
+	_, config, trace, err := BuildDockerConfig(BuildOptions{DryRun: true}, DockerOverride{
+		Name:  "nvidia",
+		Stage: StageRuntimes,
+		Apply: DockerNvidiaOverride,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.DefaultRuntime != "nvidia" {
+		t.Fatalf("expected DefaultRuntime to be nvidia, got %q", config.DefaultRuntime)
+	}
+	if len(trace) != 1 || trace[0] != "nvidia" {
+		t.Fatalf("expected trace [nvidia], got %v", trace)
+	}
+}
+
+func TestBuildContainerdConfigDryRun(t *testing.T) {
+	_, config, trace, err := BuildContainerdConfig(BuildOptions{DryRun: true}, NewSandboxImageOverride("mcr.microsoft.com/pause:3.6"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.Plugins.IoContainerdGrpcV1Cri.SandboxImage != "mcr.microsoft.com/pause:3.6" {
+		t.Fatalf("expected SandboxImage to be set, got %q", config.Plugins.IoContainerdGrpcV1Cri.SandboxImage)
+	}
+	if len(trace) != 1 || trace[0] != "sandbox-image" {
+		t.Fatalf("expected trace [sandbox-image], got %v", trace)
+	}
+}